@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bakeTarget is one `target "name" { ... }` block from a docker-bake file:
+// which Dockerfile it builds and any named build contexts
+// (`contexts = { foo = "target:other" }`) it wires to another target's
+// output.
+type bakeTarget struct {
+	Dockerfile string
+	Contexts   map[string]string
+}
+
+// bakeTargetResolved is a bakeTarget after its dockerfile default and its
+// contexts' "target:other" references have been resolved to concrete
+// Dockerfile paths, in the deterministic (alphabetical by name) order
+// combined/bake mode graphs files in.
+type bakeTargetResolved struct {
+	name       string
+	dockerfile string
+	// contexts maps a named build context used in this target's
+	// Dockerfile to the Dockerfile path of the target it resolves to.
+	contexts map[string]string
+}
+
+// parseBakeFile reads a docker-bake.hcl or docker-bake.json file and
+// returns its targets keyed by name. JSON is detected by the ".json"
+// extension; anything else is parsed as the small subset of HCL bake files
+// actually use.
+func parseBakeFile(path string) (map[string]bakeTarget, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read --bake file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseBakeJSON(content)
+	}
+	return parseBakeHCL(content)
+}
+
+type bakeJSONDoc struct {
+	Target map[string]struct {
+		Dockerfile string            `json:"dockerfile"`
+		Contexts   map[string]string `json:"contexts"`
+	} `json:"target"`
+}
+
+func parseBakeJSON(content []byte) (map[string]bakeTarget, error) {
+	var doc bakeJSONDoc
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("could not parse --bake file: %w", err)
+	}
+
+	targets := make(map[string]bakeTarget, len(doc.Target))
+	for name, t := range doc.Target {
+		targets[name] = bakeTarget{Dockerfile: t.Dockerfile, Contexts: t.Contexts}
+	}
+	return targets, nil
+}
+
+// bakeTargetPattern matches a top-level `target "name" { ... }` block.
+var bakeTargetPattern = regexp.MustCompile(`(?s)target\s+"([^"]+)"\s*\{(.*?)\n\}`)
+
+// bakeContextsPattern matches the `contexts = { ... }` map inside a target
+// block, and bakeContextsEntryPattern each `key = "value"` pair within it.
+var bakeContextsPattern = regexp.MustCompile(`(?s)contexts\s*=\s*\{(.*?)\}`)
+var bakeContextsEntryPattern = regexp.MustCompile(`(\w+)\s*=\s*"([^"]*)"`)
+
+var bakeDockerfilePattern = regexp.MustCompile(`dockerfile\s*=\s*"([^"]*)"`)
+
+// parseBakeHCL parses the small subset of docker-bake.hcl syntax this tool
+// cares about: top-level `target "name" { ... }` blocks containing
+// `dockerfile = "..."` and a `contexts = { ... }` map of quoted key/value
+// pairs. It does not support HCL functions, variables, or interpolation.
+func parseBakeHCL(content []byte) (map[string]bakeTarget, error) {
+	targets := map[string]bakeTarget{}
+
+	for _, m := range bakeTargetPattern.FindAllStringSubmatch(string(content), -1) {
+		name, body := m[1], m[2]
+
+		target := bakeTarget{}
+		if dm := bakeDockerfilePattern.FindStringSubmatch(body); dm != nil {
+			target.Dockerfile = dm[1]
+		}
+		if cm := bakeContextsPattern.FindStringSubmatch(body); cm != nil {
+			target.Contexts = map[string]string{}
+			for _, em := range bakeContextsEntryPattern.FindAllStringSubmatch(cm[1], -1) {
+				target.Contexts[em[1]] = em[2]
+			}
+		}
+		targets[name] = target
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets found in --bake file")
+	}
+	return targets, nil
+}
+
+// resolveBakeTargets fills in each target's default Dockerfile path and
+// resolves its "target:other" context references to that other target's
+// Dockerfile path, returning targets in a deterministic (alphabetical)
+// order so the combined graph's node IDs don't depend on map iteration.
+func resolveBakeTargets(raw map[string]bakeTarget) []bakeTargetResolved {
+	names := make([]string, 0, len(raw))
+	for name := range raw {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	dockerfileFor := func(t bakeTarget) string {
+		if t.Dockerfile == "" {
+			return "Dockerfile"
+		}
+		return t.Dockerfile
+	}
+
+	result := make([]bakeTargetResolved, 0, len(names))
+	for _, name := range names {
+		t := raw[name]
+
+		contexts := map[string]string{}
+		for key, value := range t.Contexts {
+			if !strings.HasPrefix(value, "target:") {
+				continue
+			}
+			other, ok := raw[strings.TrimPrefix(value, "target:")]
+			if !ok {
+				continue
+			}
+			contexts[key] = dockerfileFor(other)
+		}
+
+		result = append(result, bakeTargetResolved{
+			name:       name,
+			dockerfile: dockerfileFor(t),
+			contexts:   contexts,
+		})
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import "testing"
+
+func TestParseBakeHCL(t *testing.T) {
+	content := `
+group "default" {
+  targets = ["base", "app"]
+}
+
+target "base" {
+  dockerfile = "base/Dockerfile"
+}
+
+target "app" {
+  dockerfile = "app/Dockerfile"
+  contexts = {
+    base = "target:base"
+  }
+}
+`
+
+	targets, err := parseBakeHCL([]byte(content))
+	if err != nil {
+		t.Fatalf("parseBakeHCL() error = %v", err)
+	}
+
+	if len(targets) != 2 {
+		t.Fatalf("len(targets) = %d, want 2", len(targets))
+	}
+	if got, want := targets["base"].Dockerfile, "base/Dockerfile"; got != want {
+		t.Errorf("targets[base].Dockerfile = %q, want %q", got, want)
+	}
+	if got, want := targets["app"].Contexts["base"], "target:base"; got != want {
+		t.Errorf("targets[app].Contexts[base] = %q, want %q", got, want)
+	}
+}
+
+func TestParseBakeJSON(t *testing.T) {
+	content := `{
+  "target": {
+    "base": { "dockerfile": "base/Dockerfile" },
+    "app": {
+      "dockerfile": "app/Dockerfile",
+      "contexts": { "base": "target:base" }
+    }
+  }
+}`
+
+	targets, err := parseBakeJSON([]byte(content))
+	if err != nil {
+		t.Fatalf("parseBakeJSON() error = %v", err)
+	}
+
+	if len(targets) != 2 {
+		t.Fatalf("len(targets) = %d, want 2", len(targets))
+	}
+	if got, want := targets["app"].Contexts["base"], "target:base"; got != want {
+		t.Errorf("targets[app].Contexts[base] = %q, want %q", got, want)
+	}
+}
+
+func TestResolveBakeTargets(t *testing.T) {
+	raw := map[string]bakeTarget{
+		"base": {Dockerfile: "base/Dockerfile"},
+		"app": {
+			Dockerfile: "app/Dockerfile",
+			Contexts:   map[string]string{"base": "target:base"},
+		},
+	}
+
+	resolved := resolveBakeTargets(raw)
+	if len(resolved) != 2 {
+		t.Fatalf("len(resolved) = %d, want 2", len(resolved))
+	}
+
+	// resolveBakeTargets orders targets alphabetically by name, so "app"
+	// sorts before "base".
+	app := resolved[0]
+	if app.name != "app" {
+		t.Fatalf("resolved[0].name = %q, want %q", app.name, "app")
+	}
+	if got, want := app.contexts["base"], "base/Dockerfile"; got != want {
+		t.Errorf("app.contexts[base] = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/emicklei/dot"
+)
+
+// combinedFile is one Dockerfile folded into a multi-file graph: its parsed
+// stages, and (in --bake mode) the named build contexts its own Dockerfile
+// can reference, each mapped to the Dockerfile path of the target it
+// resolves to. bakeContexts is nil outside --bake mode.
+type combinedFile struct {
+	filename     string
+	graph        *dockerfileGraph
+	bakeContexts map[string]string
+}
+
+// crossFileTarget identifies the stage a FROM/COPY --from=/RUN
+// --mount=...,from= reference resolves to once it's been matched across
+// files, rather than within its own file.
+type crossFileTarget struct {
+	filename, stage string
+}
+
+// resolveCrossFile looks up what an external reference (one that didn't
+// resolve to a stage within its own file) actually points to:
+//
+//   - in --bake mode, a named context explicitly wired via
+//     `contexts = { ref = "target:other" }` resolves to other's last
+//     (produced) stage;
+//   - otherwise, ref is matched by name against every other file's
+//     stages - the closest approximation this tool has of "an image tag
+//     another file produces", since Dockerfiles alone don't carry real
+//     build tags.
+//
+// ok is false when ref is a genuine external image.
+func resolveCrossFile(files []combinedFile, file combinedFile, ref string) (crossFileTarget, bool) {
+	if dockerfile, ok := file.bakeContexts[ref]; ok {
+		for _, f := range files {
+			if f.filename == dockerfile {
+				last := f.graph.stages[len(f.graph.stages)-1]
+				return crossFileTarget{filename: f.filename, stage: last.name}, true
+			}
+		}
+		return crossFileTarget{}, false
+	}
+
+	for _, f := range files {
+		if f.filename == file.filename {
+			continue
+		}
+		if _, ok := f.graph.stagesByName[ref]; ok {
+			return crossFileTarget{filename: f.filename, stage: ref}, true
+		}
+	}
+	return crossFileTarget{}, false
+}
+
+// buildCombinedGraph folds several parsed Dockerfiles into one graph: each
+// file's stages are collapsed into box nodes inside their own cluster
+// subgraph, connected by the same FROM/COPY/cache-or-bind-mount edges
+// buildGraph draws for a single file, plus a new "cross-file dependency"
+// edge wherever a reference in one file resolves to another file's stage.
+//
+// --layers, --legend, and non-cache/bind mount kinds aren't supported in
+// combined/bake mode - it's a cross-file overview, not a full per-file
+// diagram.
+func buildCombinedGraph(files []combinedFile) *dot.Graph {
+	graph := dot.NewGraph(dot.Directed)
+	graph.Attr("compound", "true")
+	graph.Attr("nodesep", "1")
+	graph.Attr("rankdir", "LR")
+
+	stageNode := map[string]map[string]dot.Node{}
+	for fi, f := range files {
+		cluster := graph.Subgraph(
+			fmt.Sprintf("cluster_file_%d", fi),
+			dot.ClusterOption{},
+		)
+		cluster.Attr("label", f.filename)
+
+		nodes := map[string]dot.Node{}
+		for _, s := range f.graph.stages {
+			n := cluster.Node(fmt.Sprintf("file_%d_stage_%d", fi, s.index))
+			n.Attr("label", s.name)
+			n.Attr("shape", "box")
+			n.Attr("width", "2")
+			if s.index == len(f.graph.stages)-1 {
+				n.Attr("style", "filled,rounded")
+				n.Attr("fillcolor", "grey90")
+			} else {
+				n.Attr("style", "rounded")
+			}
+			nodes[s.name] = n
+		}
+		stageNode[f.filename] = nodes
+	}
+
+	externalImageNodes := map[string]dot.Node{}
+	externalImageNode := func(image string) dot.Node {
+		if n, ok := externalImageNodes[image]; ok {
+			return n
+		}
+		n := graph.Node(fmt.Sprintf("external_image_%d", len(externalImageNodes)))
+		n.Attr("label", image)
+		n.Attr("shape", "box")
+		n.Attr("style", "dashed,rounded")
+		n.Attr("width", "2")
+		n.Attr("color", "grey20")
+		n.Attr("fontcolor", "grey20")
+		externalImageNodes[image] = n
+		return n
+	}
+
+	// resolve returns the node a reference from file points to, and
+	// whether that node lives in another file (and so needs the
+	// cross-file edge style).
+	resolve := func(f combinedFile, ref string) (dot.Node, bool) {
+		if cross, ok := resolveCrossFile(files, f, ref); ok {
+			return stageNode[cross.filename][cross.stage], true
+		}
+		return externalImageNode(ref), false
+	}
+
+	tagCrossFile := func(e dot.Edge) {
+		e.Attr("color", "darkorchid")
+		e.Attr("fontcolor", "darkorchid")
+		e.Attr("label", "cross-file dependency")
+	}
+
+	for _, f := range files {
+		for _, s := range f.graph.stages {
+			head := stageNode[f.filename][s.name]
+
+			var from dot.Node
+			var cross bool
+			if s.fromIsStage {
+				from = stageNode[f.filename][s.from]
+			} else {
+				from, cross = resolve(f, s.from)
+			}
+			e := graph.Edge(from, head)
+			if s.fromIsStage || cross {
+				e.Attr("arrowhead", "empty")
+			}
+			if cross {
+				tagCrossFile(e)
+			}
+
+			for _, ref := range s.copyFrom {
+				var from dot.Node
+				var cross bool
+				if ref.isStage {
+					from = stageNode[f.filename][ref.target]
+				} else {
+					from, cross = resolve(f, ref.target)
+				}
+				e := graph.Edge(from, head)
+				e.Attr("arrowhead", "empty")
+				if cross {
+					tagCrossFile(e)
+				}
+			}
+
+			for _, m := range s.mounts {
+				if m.kind != mountKindCache && m.kind != mountKindBind {
+					continue
+				}
+
+				var from dot.Node
+				var cross bool
+				if m.ref.isStage {
+					from = stageNode[f.filename][m.ref.target]
+				} else {
+					from, cross = resolve(f, m.ref.target)
+				}
+				e := graph.Edge(from, head)
+				e.Attr("arrowhead", mountStyles[m.kind].arrowhead)
+				if cross {
+					tagCrossFile(e)
+				}
+			}
+		}
+	}
+
+	return graph
+}
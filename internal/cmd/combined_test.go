@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestResolveFilenamesExpandsGlobsAndDedupes(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	_ = afero.WriteFile(fs, "services/a/Dockerfile", []byte(""), 0644)
+	_ = afero.WriteFile(fs, "services/b/Dockerfile", []byte(""), 0644)
+
+	got, err := resolveFilenames(fs, []string{
+		"services/*/Dockerfile",
+		"services/a/Dockerfile", // already matched by the glob above
+	})
+	if err != nil {
+		t.Fatalf("resolveFilenames() error = %v", err)
+	}
+
+	want := []string{"services/a/Dockerfile", "services/b/Dockerfile"}
+	if len(got) != len(want) {
+		t.Fatalf("resolveFilenames() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("resolveFilenames()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBuildCombinedGraphLinksStagesAcrossFiles(t *testing.T) {
+	baseGraph, err := parseDockerfile("FROM alpine AS base\nRUN echo base\n", nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile(base) error = %v", err)
+	}
+	appGraph, err := parseDockerfile("FROM base AS app\nRUN echo app\n", nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile(app) error = %v", err)
+	}
+
+	files := []combinedFile{
+		{filename: "base/Dockerfile", graph: baseGraph},
+		{filename: "app/Dockerfile", graph: appGraph},
+	}
+
+	source := buildCombinedGraph(files).String()
+
+	for _, want := range []string{
+		`label="base/Dockerfile"`,
+		`label="app/Dockerfile"`,
+		"cross-file dependency",
+		"darkorchid",
+		`label="alpine"`,
+	} {
+		if !strings.Contains(source, want) {
+			t.Errorf("combined graph source missing %q:\n%s", want, source)
+		}
+	}
+}
+
+func TestBuildCombinedGraphLeavesExternalImageFromWithDefaultArrowhead(t *testing.T) {
+	graph, err := parseDockerfile("FROM alpine\n", nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	files := []combinedFile{
+		{filename: "Dockerfile", graph: graph},
+	}
+
+	source := buildCombinedGraph(files).String()
+	if strings.Contains(source, `arrowhead="empty"`) {
+		t.Errorf("a FROM that resolves to a genuine external image should keep the default arrowhead:\n%s", source)
+	}
+}
+
+func TestBuildCombinedGraphResolvesBakeNamedContexts(t *testing.T) {
+	baseGraph, err := parseDockerfile("FROM alpine AS build\nRUN echo build\n", nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile(base) error = %v", err)
+	}
+	// app's Dockerfile references "base" as a named build context, not as
+	// another stage in its own file or a literal image - only resolvable
+	// via the target's bakeContexts.
+	appGraph, err := parseDockerfile("FROM base AS app\nRUN echo app\n", nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile(app) error = %v", err)
+	}
+
+	files := []combinedFile{
+		{filename: "base/Dockerfile", graph: baseGraph},
+		{
+			filename:     "app/Dockerfile",
+			graph:        appGraph,
+			bakeContexts: map[string]string{"base": "base/Dockerfile"},
+		},
+	}
+
+	source := buildCombinedGraph(files).String()
+	if !strings.Contains(source, "cross-file dependency") {
+		t.Errorf("expected the named context to produce a cross-file dependency edge:\n%s", source)
+	}
+	if strings.Contains(source, `label=base`) {
+		t.Errorf("\"base\" should resolve via the named context, not become its own external image node:\n%s", source)
+	}
+}
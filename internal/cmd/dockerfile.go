@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+	"github.com/moby/buildkit/frontend/dockerfile/shell"
+)
+
+// mountKind identifies the kind of RUN --mount this tool knows how to turn
+// into a graph edge or node.
+type mountKind string
+
+const (
+	mountKindCache  mountKind = "cache"
+	mountKindBind   mountKind = "bind"
+	mountKindSecret mountKind = "secret"
+	mountKindSSH    mountKind = "ssh"
+	mountKindTmpfs  mountKind = "tmpfs"
+)
+
+// layer represents a single instruction within a stage, rendered as its own
+// node when --layers is set.
+type layer struct {
+	// label is the raw instruction text, e.g. "RUN apt-get update" - or,
+	// for an instruction using heredoc syntax, a condensed one-line
+	// summary such as "RUN <<EOF (2 lines)".
+	label string
+	// tooltip holds the full, uncondensed instruction text and is only
+	// set when label has been condensed from a heredoc, so SVG output can
+	// surface the real body on hover.
+	tooltip string
+}
+
+// newLayer builds a layer from an instruction node, condensing it if it
+// contains a heredoc. The buildkit parser keeps a heredoc's body out of
+// node.Original - it's attached separately on node.Heredocs - so that body
+// has to be stitched back in before condenseHeredocs has anything to
+// collapse.
+func newLayer(node *parser.Node) layer {
+	original := withHeredocBodies(node)
+	label, ok := condenseHeredocs(original)
+	if !ok {
+		return layer{label: node.Original}
+	}
+	return layer{label: label, tooltip: original}
+}
+
+// withHeredocBodies reconstructs an instruction's full multi-line text by
+// appending each heredoc's body and terminator after node.Original, in the
+// order the heredocs appear in the instruction.
+func withHeredocBodies(node *parser.Node) string {
+	if len(node.Heredocs) == 0 {
+		return node.Original
+	}
+
+	var b strings.Builder
+	b.WriteString(node.Original)
+	for _, h := range node.Heredocs {
+		b.WriteString("\n")
+		if body := strings.TrimSuffix(h.Content, "\n"); body != "" {
+			b.WriteString(body)
+			b.WriteString("\n")
+		}
+		b.WriteString(h.Name)
+	}
+	return b.String()
+}
+
+// stage is one `FROM ... AS <name>` block of the Dockerfile.
+type stage struct {
+	name   string // the AS name, or the stage index as a string if unnamed
+	index  int
+	layers []layer
+
+	// from is the resolved parent this stage's FROM refers to: either the
+	// name of an earlier stage, or an external image reference.
+	from        string
+	fromIsStage bool
+	copyFrom    []edgeRef
+	mounts      []mountRef
+}
+
+// edgeRef is a reference to another stage or an external image, discovered
+// via COPY --from= or RUN --mount=...,from=.
+type edgeRef struct {
+	target  string
+	isStage bool
+}
+
+// mountRef is a single RUN --mount=... flag, resolved to whatever it
+// depends on: another stage or external image for cache/bind mounts, or a
+// secret/SSH agent ID for secret/ssh mounts. tmpfs mounts carry neither -
+// they're tracked only so --legend can mention them.
+type mountRef struct {
+	kind mountKind
+	ref  edgeRef // set for cache and bind mounts
+	id   string  // set for secret and ssh mounts
+}
+
+// dockerfileGraph is the fully resolved set of stages and external images
+// that make up a parsed Dockerfile.
+type dockerfileGraph struct {
+	stages         []*stage
+	stagesByName   map[string]*stage
+	externalImages []string // in first-seen order
+}
+
+// parseDockerfile parses the given Dockerfile content, resolving ARG/ENV
+// variable references in FROM, COPY --from= and RUN --mount=...,from=
+// arguments along the way.
+//
+// buildArgs mirrors `docker build --build-arg`: it overrides the default
+// value of any ARG declared in the Dockerfile. Unresolved ${...} references
+// are left as-is in the returned graph, and a warning is written to warn.
+func parseDockerfile(
+	content string,
+	buildArgs map[string]string,
+	warn io.Writer,
+) (*dockerfileGraph, error) {
+	result, err := parser.Parse(strings.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &dockerfileGraph{
+		stagesByName: map[string]*stage{},
+	}
+
+	// Global ARGs are every `ARG` declared before the first FROM. Per the
+	// Dockerfile spec, these are the only ARGs available for expansion in a
+	// FROM instruction's arguments.
+	globalArgs := map[string]string{}
+	var currentStage *stage
+	var stageEnv map[string]string
+	seenFrom := false
+
+	lex := shell.NewLex('\\')
+
+	for _, child := range result.AST.Children {
+		switch strings.ToLower(child.Value) {
+		case "arg":
+			for _, a := range argAssignments(child) {
+				name, value, hasValue := a.name, a.value, a.hasValue
+				if override, ok := buildArgs[name]; ok {
+					value, hasValue = override, true
+				}
+				if !seenFrom {
+					if hasValue {
+						globalArgs[name] = value
+					}
+				} else if currentStage != nil {
+					// A stage only inherits a global ARG's value once it
+					// redeclares that ARG itself - per the Dockerfile/BuildKit
+					// scoping rule, declaring it before the first FROM isn't
+					// enough on its own.
+					if hasValue {
+						stageEnv[name] = value
+					} else if globalValue, ok := globalArgs[name]; ok {
+						stageEnv[name] = globalValue
+					}
+				}
+			}
+		case "env":
+			if currentStage == nil {
+				continue
+			}
+			for _, a := range envAssignments(child) {
+				stageEnv[a.name] = a.value
+			}
+		case "from":
+			seenFrom = true
+			raw := nodeArgsString(child)
+			fields := strings.Fields(raw)
+			if len(fields) == 0 {
+				continue
+			}
+			baseImage := expand(lex, fields[0], globalArgs, warn)
+			name := strconv.Itoa(len(graph.stages))
+			for i := 0; i < len(fields)-1; i++ {
+				if strings.EqualFold(fields[i], "as") {
+					name = fields[i+1]
+				}
+			}
+
+			st := &stage{name: name, index: len(graph.stages)}
+			if parent, ok := graph.stagesByName[baseImage]; ok {
+				st.from = parent.name
+				st.fromIsStage = true
+			} else {
+				st.from = baseImage
+				st.fromIsStage = false
+				addExternalImage(graph, baseImage)
+			}
+
+			graph.stages = append(graph.stages, st)
+			graph.stagesByName[name] = st
+			currentStage = st
+			stageEnv = map[string]string{}
+
+			st.layers = append(st.layers, newLayer(child))
+		case "copy":
+			if currentStage == nil {
+				continue
+			}
+			if from, ok := flagValue(child.Flags, "from"); ok {
+				target := expand(lex, from, stageEnv, warn)
+				currentStage.copyFrom = append(
+					currentStage.copyFrom,
+					resolveRef(graph, target),
+				)
+			}
+			currentStage.layers = append(currentStage.layers, newLayer(child))
+		case "run":
+			if currentStage == nil {
+				continue
+			}
+			for _, mount := range flagValues(child.Flags, "mount") {
+				kind, from, id := parseMount(mount)
+				switch kind {
+				case mountKindCache, mountKindBind:
+					target := expand(lex, from, stageEnv, warn)
+					currentStage.mounts = append(currentStage.mounts, mountRef{
+						kind: kind,
+						ref:  resolveRef(graph, target),
+					})
+				case mountKindSecret, mountKindSSH:
+					currentStage.mounts = append(currentStage.mounts, mountRef{
+						kind: kind,
+						id:   id,
+					})
+				case mountKindTmpfs:
+					currentStage.mounts = append(currentStage.mounts, mountRef{kind: kind})
+				}
+			}
+			currentStage.layers = append(currentStage.layers, newLayer(child))
+		default:
+			if currentStage != nil {
+				currentStage.layers = append(currentStage.layers, newLayer(child))
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+func resolveRef(graph *dockerfileGraph, target string) edgeRef {
+	if _, ok := graph.stagesByName[target]; ok {
+		return edgeRef{target: target, isStage: true}
+	}
+	addExternalImage(graph, target)
+	return edgeRef{target: target, isStage: false}
+}
+
+func addExternalImage(graph *dockerfileGraph, image string) {
+	for _, existing := range graph.externalImages {
+		if existing == image {
+			return
+		}
+	}
+	graph.externalImages = append(graph.externalImages, image)
+}
+
+// varRefPattern matches a ${name} or $name shell-style variable reference.
+var varRefPattern = regexp.MustCompile(`\$\{[A-Za-z_][A-Za-z0-9_]*\}|\$[A-Za-z_][A-Za-z0-9_]*`)
+
+// expand resolves ${name}/$name references in s using env. Any reference
+// that can't be resolved is left untouched in its original literal form and
+// reported on warn, rather than being silently replaced with an empty
+// string.
+func expand(lex *shell.Lex, s string, env map[string]string, warn io.Writer) string {
+	var unresolved []string
+	masked := varRefPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := strings.Trim(match, "${}")
+		if _, ok := env[name]; ok {
+			return match
+		}
+		placeholder := fmt.Sprintf("__dockerfilegraph_unresolved_%d__", len(unresolved))
+		unresolved = append(unresolved, match)
+		return placeholder
+	})
+
+	envSlice := make([]string, 0, len(env))
+	for k, v := range env {
+		envSlice = append(envSlice, k+"="+v)
+	}
+
+	result, err := lex.ProcessWord(masked, envSlice)
+	if err != nil {
+		result = masked
+	}
+
+	for i, original := range unresolved {
+		placeholder := fmt.Sprintf("__dockerfilegraph_unresolved_%d__", i)
+		result = strings.Replace(result, placeholder, original, 1)
+	}
+
+	if len(unresolved) > 0 && warn != nil {
+		fmt.Fprintf(warn, "warning: could not resolve all variables in %q\n", s)
+	}
+
+	return result
+}
+
+// argAssignment is a single `name[=value]` pair out of an ARG or ENV
+// instruction.
+type argAssignment struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// argAssignments splits an `ARG name1[=value1] name2[=value2] ...`
+// instruction into one assignment per name. The buildkit parser keeps each
+// `name=value` (or bare `name`) as its own node in the chain, already split
+// on whitespace, so only the "=" within each token needs splitting here.
+func argAssignments(node *parser.Node) []argAssignment {
+	var assignments []argAssignment
+	for n := node.Next; n != nil; n = n.Next {
+		if idx := strings.IndexByte(n.Value, '='); idx != -1 {
+			assignments = append(assignments, argAssignment{
+				name: n.Value[:idx], value: n.Value[idx+1:], hasValue: true,
+			})
+		} else {
+			assignments = append(assignments, argAssignment{name: n.Value})
+		}
+	}
+	return assignments
+}
+
+// envAssignments splits an `ENV name1=value1 name2=value2 ...` (or legacy
+// `ENV name value`) instruction into one assignment per name. The buildkit
+// parser already flattens both forms into an alternating
+// name, value, name, value, ... chain.
+func envAssignments(node *parser.Node) []argAssignment {
+	var assignments []argAssignment
+	for n := node.Next; n != nil && n.Next != nil; n = n.Next.Next {
+		assignments = append(assignments, argAssignment{
+			name: n.Value, value: n.Next.Value, hasValue: true,
+		})
+	}
+	return assignments
+}
+
+// nodeArgsString reconstructs the instruction's arguments as a single
+// space-separated string by walking the buildkit AST's linked-list of
+// tokens.
+func nodeArgsString(node *parser.Node) string {
+	var b strings.Builder
+	for n := node.Next; n != nil; n = n.Next {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(n.Value)
+	}
+	return b.String()
+}
+
+func flagValue(flags []string, name string) (string, bool) {
+	values := flagValues(flags, name)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// flagValues returns the value of every "--name=value" flag, in the order
+// they appear - a RUN instruction may carry more than one --mount flag.
+func flagValues(flags []string, name string) []string {
+	prefix := "--" + name + "="
+	var values []string
+	for _, f := range flags {
+		if strings.HasPrefix(f, prefix) {
+			values = append(values, strings.TrimPrefix(f, prefix))
+		}
+	}
+	return values
+}
+
+// defaultSSHID is the ID BuildKit assigns an `--mount=type=ssh` that
+// doesn't set `id=`.
+const defaultSSHID = "default"
+
+// parseMount breaks down a `--mount=...` flag value into its type and,
+// depending on that type, either the stage/image it depends on (cache,
+// bind) or the secret/SSH agent ID it expects to be forwarded (secret,
+// ssh). tmpfs mounts carry neither.
+func parseMount(mount string) (kind mountKind, from, id string) {
+	kind = mountKindBind // BuildKit's own default when `type=` is omitted
+	for _, f := range strings.Split(mount, ",") {
+		k, v, _ := strings.Cut(f, "=")
+		switch k {
+		case "type":
+			kind = mountKind(v)
+		case "from":
+			from = v
+		case "id":
+			id = v
+		}
+	}
+	if kind == mountKindSSH && id == "" {
+		id = defaultSSHID
+	}
+	return kind, from, id
+}
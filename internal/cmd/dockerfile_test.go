@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseDockerfileResolvesBuildArgs(t *testing.T) {
+	content := `
+ARG BASE=alpine:3.18
+ARG GO_VERSION
+
+FROM ${BASE} AS builder
+ARG GO_VERSION
+RUN echo ${GO_VERSION}
+
+FROM golang:${GO_VERSION} AS final
+COPY --from=builder /out /out
+`
+
+	warn := new(bytes.Buffer)
+	graph, err := parseDockerfile(content, map[string]string{"GO_VERSION": "1.19"}, warn)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	if warn.Len() != 0 {
+		t.Errorf("expected no warnings, got %q", warn.String())
+	}
+
+	wantImages := []string{"alpine:3.18", "golang:1.19"}
+	if len(graph.externalImages) != len(wantImages) {
+		t.Fatalf("externalImages = %v, want %v", graph.externalImages, wantImages)
+	}
+	for i, want := range wantImages {
+		if graph.externalImages[i] != want {
+			t.Errorf("externalImages[%d] = %q, want %q", i, graph.externalImages[i], want)
+		}
+	}
+
+	final := graph.stagesByName["final"]
+	if final == nil {
+		t.Fatal("stage \"final\" not found")
+	}
+	if final.from != "golang:1.19" {
+		t.Errorf("final.from = %q, want %q", final.from, "golang:1.19")
+	}
+}
+
+func TestParseDockerfileDoesNotInheritAGlobalArgWithoutRedeclaration(t *testing.T) {
+	content := `
+ARG REPO=myrepo
+
+FROM alpine AS base
+COPY --from=${REPO} /x /x
+`
+
+	warn := new(bytes.Buffer)
+	graph, err := parseDockerfile(content, nil, warn)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	if warn.Len() == 0 {
+		t.Error("expected a warning about an unresolved build arg, got none")
+	}
+
+	base := graph.stagesByName["base"]
+	if base == nil {
+		t.Fatal("stage \"base\" not found")
+	}
+	if len(base.copyFrom) != 1 {
+		t.Fatalf("base.copyFrom = %v, want 1 entry", base.copyFrom)
+	}
+	if got, want := base.copyFrom[0].target, "${REPO}"; got != want {
+		t.Errorf("copyFrom[0].target = %q, want %q", got, want)
+	}
+}
+
+func TestParseDockerfileResolvesMultiAssignmentArgAndEnv(t *testing.T) {
+	content := `
+ARG VAR1=img1 VAR2=img2
+
+FROM ${VAR1} AS base
+ENV DEST1=out1 DEST2=out2
+COPY --from=${DEST1} /c /c
+COPY --from=${DEST2} /d /d
+
+FROM ${VAR2} AS other
+`
+
+	warn := new(bytes.Buffer)
+	graph, err := parseDockerfile(content, nil, warn)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	if warn.Len() != 0 {
+		t.Errorf("expected no warnings, got %q", warn.String())
+	}
+
+	base := graph.stagesByName["base"]
+	if base == nil {
+		t.Fatal("stage \"base\" not found")
+	}
+	if base.from != "img1" {
+		t.Errorf("base.from = %q, want %q", base.from, "img1")
+	}
+	if other := graph.stagesByName["other"]; other == nil || other.from != "img2" {
+		t.Errorf("other.from = %+v, want %q", other, "img2")
+	}
+
+	want := []string{"out1", "out2"}
+	if len(base.copyFrom) != len(want) {
+		t.Fatalf("base.copyFrom = %v, want %v", base.copyFrom, want)
+	}
+	for i, w := range want {
+		if got := base.copyFrom[i].target; got != w {
+			t.Errorf("copyFrom[%d].target = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestParseDockerfileWarnsOnUnresolvedArg(t *testing.T) {
+	content := `
+ARG BASE
+
+FROM ${BASE} AS builder
+`
+
+	warn := new(bytes.Buffer)
+	graph, err := parseDockerfile(content, nil, warn)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	if warn.Len() == 0 {
+		t.Error("expected a warning about an unresolved build arg, got none")
+	}
+
+	if got, want := graph.stages[0].from, "${BASE}"; got != want {
+		t.Errorf("stages[0].from = %q, want %q", got, want)
+	}
+}
+
+func TestParseDockerfileRecognizesEveryMountKind(t *testing.T) {
+	content := `
+FROM golang:1.19 AS build
+RUN --mount=type=cache,from=buildcache,target=/go/pkg/mod/cache/ \
+    --mount=type=bind,from=vendor,source=/vendor,target=/vendor \
+    --mount=type=secret,id=npmrc \
+    --mount=type=ssh \
+    --mount=type=tmpfs,target=/tmp \
+    go build
+`
+
+	graph, err := parseDockerfile(content, nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	build := graph.stagesByName["build"]
+	if build == nil {
+		t.Fatal("stage \"build\" not found")
+	}
+	if len(build.mounts) != 5 {
+		t.Fatalf("len(build.mounts) = %d, want 5", len(build.mounts))
+	}
+
+	wantKinds := []mountKind{
+		mountKindCache, mountKindBind, mountKindSecret, mountKindSSH, mountKindTmpfs,
+	}
+	for i, want := range wantKinds {
+		if got := build.mounts[i].kind; got != want {
+			t.Errorf("build.mounts[%d].kind = %q, want %q", i, got, want)
+		}
+	}
+
+	if got, want := build.mounts[2].id, "npmrc"; got != want {
+		t.Errorf("secret mount id = %q, want %q", got, want)
+	}
+	if got, want := build.mounts[3].id, defaultSSHID; got != want {
+		t.Errorf("ssh mount id = %q, want %q", got, want)
+	}
+}
+
+func TestParseDockerfileCondensesHeredocLayers(t *testing.T) {
+	content := "FROM alpine AS build\n" +
+		"RUN <<EOF\n" +
+		"apt-get update\n" +
+		"apt-get install -y foo\n" +
+		"EOF\n"
+
+	graph, err := parseDockerfile(content, nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	build := graph.stagesByName["build"]
+	if build == nil {
+		t.Fatal("stage \"build\" not found")
+	}
+	if len(build.layers) != 2 {
+		t.Fatalf("len(build.layers) = %d, want 2", len(build.layers))
+	}
+
+	run := build.layers[1]
+	if want := "RUN <<EOF (2 lines)"; run.label != want {
+		t.Errorf("run layer label = %q, want %q", run.label, want)
+	}
+	if run.tooltip == "" {
+		t.Error("expected the full heredoc body to be preserved in tooltip")
+	}
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/emicklei/dot"
+)
+
+// edgeKind identifies why an edge exists, so that SVG output can tag it
+// with a CSS class for styling.
+type edgeKind string
+
+const (
+	edgeKindFrom  edgeKind = "edge-from"
+	edgeKindCopy  edgeKind = "edge-copy"
+	edgeKindMount edgeKind = "edge-mount"
+)
+
+// mountEdgeStyle describes how a given RUN --mount kind is drawn: the
+// arrowhead it gets when it points at an existing stage/image node (cache,
+// bind), or the edge style plus a glyph label for a mount that gets its own
+// node (secret, ssh).
+type mountEdgeStyle struct {
+	arrowhead string
+	edgeStyle string // additional Graphviz "style" value for the edge, if any
+	glyph     string // label prefix for a dedicated secret/ssh node
+}
+
+var mountStyles = map[mountKind]mountEdgeStyle{
+	mountKindCache:  {arrowhead: "ediamond"},
+	mountKindBind:   {arrowhead: "obox"},
+	mountKindSecret: {arrowhead: "odot", glyph: "\U0001F512 "},    // lock
+	mountKindSSH:    {arrowhead: "odiamond", glyph: "\U0001F511 "}, // key
+	mountKindTmpfs:  {edgeStyle: "dotted"},
+}
+
+// buildGraph turns a parsed Dockerfile into a Graphviz graph. When
+// showLayers is set, every instruction gets its own node, clustered per
+// stage; otherwise each stage is collapsed into a single node.
+//
+// withClasses additionally tags every edge with a "class" attribute
+// identifying its edgeKind, which graphviz's SVG output carries through
+// onto the rendered <g> elements so they can be styled with CSS. It's only
+// enabled for --output svg, so it doesn't perturb the dot/canon/pdf/png
+// output other formats are golden-tested against.
+//
+// It also returns the graph's representative node per stage (the stage
+// node itself, or its first layer under --layers) and per external image,
+// which --plan uses to group stages by level (external images at level 0)
+// and highlight the critical path.
+func buildGraph(g *dockerfileGraph, showLayers, withClasses bool) (*dot.Graph, map[string]dot.Node, map[string]dot.Node) {
+	graph := dot.NewGraph(dot.Directed)
+	graph.Attr("compound", "true")
+	graph.Attr("nodesep", "1")
+	graph.Attr("rankdir", "LR")
+
+	externalImageNodes := map[string]dot.Node{}
+	for i, image := range g.externalImages {
+		n := graph.Node(fmt.Sprintf("external_image_%d", i))
+		n.Attr("label", image)
+		n.Attr("shape", "box")
+		n.Attr("style", "dashed,rounded")
+		n.Attr("width", "2")
+		n.Attr("color", "grey20")
+		n.Attr("fontcolor", "grey20")
+		externalImageNodes[image] = n
+	}
+
+	isLastStage := func(s *stage) bool {
+		return s.index == len(g.stages)-1
+	}
+
+	stageHeadNode := map[string]dot.Node{}
+	stageTailNode := map[string]dot.Node{}
+
+	for _, s := range g.stages {
+		if !showLayers {
+			n := graph.Node(fmt.Sprintf("stage_%d", s.index))
+			n.Attr("label", s.name)
+			n.Attr("shape", "box")
+			n.Attr("width", "2")
+			if isLastStage(s) {
+				n.Attr("style", "filled,rounded")
+				n.Attr("fillcolor", "grey90")
+			} else {
+				n.Attr("style", "rounded")
+			}
+			stageHeadNode[s.name] = n
+			stageTailNode[s.name] = n
+			continue
+		}
+
+		cluster := graph.Subgraph(
+			fmt.Sprintf("cluster_stage_%d", s.index),
+			dot.ClusterOption{},
+		)
+		cluster.Attr("label", s.name)
+		cluster.Attr("margin", "16")
+		if isLastStage(s) {
+			cluster.Attr("style", "filled")
+			cluster.Attr("fillcolor", "grey90")
+		}
+
+		var prev dot.Node
+		for i, l := range s.layers {
+			n := cluster.Node(fmt.Sprintf("stage_%d_layer_%d", s.index, i))
+			n.Attr("label", layerLabel(l))
+			n.Attr("shape", "box")
+			n.Attr("style", "filled,rounded")
+			n.Attr("fillcolor", "white")
+			n.Attr("width", "2")
+			n.Attr("penwidth", "0.5")
+			if l.tooltip != "" {
+				n.Attr("tooltip", l.tooltip)
+			}
+			if i == 0 {
+				stageHeadNode[s.name] = n
+			}
+			stageTailNode[s.name] = n
+			if i > 0 {
+				cluster.Edge(prev, n)
+			}
+			prev = n
+		}
+	}
+
+	mountInputNodes := map[string]dot.Node{}
+
+	for _, s := range g.stages {
+		head := stageHeadNode[s.name]
+
+		tagEdge := func(e dot.Edge, kind edgeKind) {
+			if withClasses {
+				e.Attr("class", string(kind))
+			}
+		}
+
+		if s.fromIsStage {
+			tail := stageTailNode[s.from]
+			e := graph.Edge(tail, head)
+			e.Attr("arrowhead", "empty")
+			if showLayers {
+				e.Attr("ltail", fmt.Sprintf("cluster_stage_%d", g.stagesByName[s.from].index))
+			}
+			tagEdge(e, edgeKindFrom)
+		} else {
+			e := graph.Edge(externalImageNodes[s.from], head)
+			tagEdge(e, edgeKindFrom)
+		}
+
+		for _, ref := range s.copyFrom {
+			var from dot.Node
+			if ref.isStage {
+				from = stageTailNode[ref.target]
+			} else {
+				from = externalImageNodes[ref.target]
+			}
+			e := graph.Edge(from, head)
+			e.Attr("arrowhead", "empty")
+			tagEdge(e, edgeKindCopy)
+		}
+
+		for _, m := range s.mounts {
+			style := mountStyles[m.kind]
+
+			switch m.kind {
+			case mountKindCache, mountKindBind:
+				var from dot.Node
+				if m.ref.isStage {
+					from = stageTailNode[m.ref.target]
+				} else {
+					from = externalImageNodes[m.ref.target]
+				}
+				e := graph.Edge(from, head)
+				e.Attr("arrowhead", style.arrowhead)
+				tagEdge(e, edgeKindMount)
+
+			case mountKindSecret, mountKindSSH:
+				from := mountInputNode(graph, mountInputNodes, m.kind, m.id, style.glyph)
+				e := graph.Edge(from, head)
+				e.Attr("arrowhead", style.arrowhead)
+				tagEdge(e, edgeKindMount)
+
+			case mountKindTmpfs:
+				// tmpfs mounts are ephemeral and depend on nothing else;
+				// a dotted self-loop flags that the stage uses one.
+				e := graph.Edge(head, head)
+				e.Attr("style", style.edgeStyle)
+				tagEdge(e, edgeKindMount)
+			}
+		}
+	}
+
+	return graph, stageHeadNode, externalImageNodes
+}
+
+// mountInputNode returns the node representing a secret/ssh mount's ID,
+// creating it (deduplicated by kind+id, since multiple RUN instructions
+// commonly forward the same secret) the first time it's seen.
+func mountInputNode(
+	graph *dot.Graph,
+	nodes map[string]dot.Node,
+	kind mountKind,
+	id, glyph string,
+) dot.Node {
+	key := string(kind) + "/" + id
+	if n, ok := nodes[key]; ok {
+		return n
+	}
+
+	n := graph.Node(fmt.Sprintf("%s_input_%d", kind, len(nodes)))
+	n.Attr("label", glyph+id)
+	n.Attr("shape", "note")
+	nodes[key] = n
+	return n
+}
+
+const layerLabelMaxLen = 17
+
+// truncateLabel shortens a layer's instruction text to keep node sizes
+// consistent, matching the width of a collapsed stage node.
+func truncateLabel(s string) string {
+	runes := []rune(s)
+	if len(runes) <= layerLabelMaxLen {
+		return s
+	}
+	return string(runes[:layerLabelMaxLen]) + "..."
+}
+
+// layerLabel returns a layer's node label. A heredoc's label is already a
+// short, deliberately condensed summary (e.g. "RUN <<EOF (2 lines)"), so it
+// is used as-is instead of being cut again by truncateLabel.
+func layerLabel(l layer) string {
+	if l.tooltip != "" {
+		return l.label
+	}
+	return truncateLabel(l.label)
+}
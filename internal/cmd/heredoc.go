@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// heredocPattern matches a heredoc redirection word: <<WORD, <<-WORD (the
+// indent-stripping form), <<'WORD', or <<"WORD". Group 1 captures the "-",
+// and exactly one of groups 2-4 captures the delimiter word.
+var heredocPattern = regexp.MustCompile(`<<(-?)(?:'(\w+)'|"(\w+)"|(\w+))`)
+
+// condenseHeredocs collapses every heredoc in a RUN/COPY instruction's raw
+// text into a single-line summary, e.g. turning:
+//
+//	RUN <<EOF
+//	apt-get update
+//	apt-get install -y foo
+//	EOF
+//
+// into "RUN <<EOF (2 lines)". A line is only a terminator once its
+// leading tabs are stripped for the <<- form. ok is false (and label is the
+// unmodified original) when the instruction has no heredoc.
+func condenseHeredocs(original string) (label string, ok bool) {
+	lines := strings.Split(original, "\n")
+	if len(lines) < 2 {
+		return original, false
+	}
+
+	var out []string
+	found := false
+	i := 0
+	for i < len(lines) {
+		line := lines[i]
+		i++
+
+		for _, m := range heredocPattern.FindAllStringSubmatch(line, -1) {
+			found = true
+			strip := m[1] == "-"
+			word := m[2] + m[3] + m[4]
+
+			lineCount := 0
+			for i < len(lines) {
+				terminator := lines[i]
+				if strip {
+					terminator = strings.TrimLeft(terminator, "\t")
+				}
+				i++
+				if terminator == word {
+					break
+				}
+				lineCount++
+			}
+
+			line = strings.Replace(
+				line, m[0],
+				fmt.Sprintf("<<%s (%d line%s)", word, lineCount, plural(lineCount)),
+				1,
+			)
+		}
+
+		out = append(out, line)
+	}
+
+	if !found {
+		return original, false
+	}
+	return strings.Join(out, "; "), true
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import "testing"
+
+func TestCondenseHeredocs(t *testing.T) {
+	tests := []struct {
+		name      string
+		original  string
+		wantLabel string
+		wantOK    bool
+	}{
+		{
+			name:      "no heredoc",
+			original:  "RUN apt-get update",
+			wantLabel: "RUN apt-get update",
+			wantOK:    false,
+		},
+		{
+			name:      "single heredoc",
+			original:  "RUN <<EOF\napt-get update\napt-get install -y foo\nEOF",
+			wantLabel: "RUN <<EOF (2 lines)",
+			wantOK:    true,
+		},
+		{
+			name:      "empty heredoc body",
+			original:  "RUN <<EOF\nEOF",
+			wantLabel: "RUN <<EOF (0 lines)",
+			wantOK:    true,
+		},
+		{
+			name: "multiple heredocs in one RUN",
+			original: "RUN cat <<FILE1 > a && cat <<FILE2 > b\n" +
+				"line one\nFILE1\nline two\nline three\nFILE2",
+			wantLabel: "RUN cat <<FILE1 (1 line) > a && cat <<FILE2 (2 lines) > b",
+			wantOK:    true,
+		},
+		{
+			name:      "indent-stripping <<- form",
+			original:  "RUN <<-EOF\n\tapt-get update\n\tEOF",
+			wantLabel: "RUN <<EOF (1 line)",
+			wantOK:    true,
+		},
+		{
+			name:      "quoted delimiter",
+			original:  "RUN <<'EOF'\necho $HOME\nEOF",
+			wantLabel: "RUN <<EOF (1 line)",
+			wantOK:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label, ok := condenseHeredocs(tt.original)
+			if ok != tt.wantOK {
+				t.Errorf("condenseHeredocs() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if label != tt.wantLabel {
+				t.Errorf("condenseHeredocs() label = %q, want %q", label, tt.wantLabel)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/emicklei/dot"
+)
+
+// legendEntry is one row of the --legend key, pairing a sample instruction
+// with the arrowhead (and optional edge style) it's rendered with.
+type legendEntry struct {
+	label     string
+	arrowhead string
+	edgeStyle string
+}
+
+var mountLegendLabel = map[mountKind]string{
+	mountKindCache:  "RUN --mount=type=cache,from=...",
+	mountKindBind:   "RUN --mount=type=bind,from=...",
+	mountKindSecret: "RUN --mount=type=secret,id=...",
+	mountKindSSH:    "RUN --mount=type=ssh,id=...",
+	mountKindTmpfs:  "RUN --mount=type=tmpfs",
+}
+
+// legendEntriesFor builds the --legend key for the edge kinds actually
+// present in g: FROM and COPY --from= are always shown, and one row is
+// added per RUN --mount kind that's actually used.
+func legendEntriesFor(g *dockerfileGraph) []legendEntry {
+	entries := []legendEntry{
+		{label: "FROM ..."},
+		{label: "COPY --from=...", arrowhead: "empty"},
+	}
+
+	seen := map[mountKind]bool{}
+	for _, order := range []mountKind{
+		mountKindCache, mountKindBind, mountKindSecret, mountKindSSH, mountKindTmpfs,
+	} {
+		for _, s := range g.stages {
+			for _, m := range s.mounts {
+				if m.kind == order && !seen[order] {
+					seen[order] = true
+					style := mountStyles[order]
+					entries = append(entries, legendEntry{
+						label:     mountLegendLabel[order],
+						arrowhead: style.arrowhead,
+						edgeStyle: style.edgeStyle,
+					})
+				}
+			}
+		}
+	}
+
+	return entries
+}
+
+// addLegend draws a small key in its own cluster: a left-hand HTML table of
+// sample instructions and a right-hand blank table, connected port-to-port
+// by edges using the arrowhead/style each instruction produces in the graph
+// above.
+func addLegend(graph *dot.Graph, g *dockerfileGraph) {
+	entries := legendEntriesFor(g)
+
+	cluster := graph.Subgraph("cluster_legend", dot.ClusterOption{})
+
+	var left, right strings.Builder
+	left.WriteString(`<table border="0" cellpadding="2" cellspacing="0" cellborder="0">` + "\n")
+	right.WriteString(`<table border="0" cellpadding="2" cellspacing="0" cellborder="0">` + "\n")
+	for i, entry := range entries {
+		fmt.Fprintf(&left, "\t<tr><td align=\"right\" port=\"i%d\">%s&nbsp;</td></tr>\n", i, entry.label)
+		fmt.Fprintf(&right, "\t<tr><td port=\"i%d\">&nbsp;</td></tr>\n", i)
+	}
+	left.WriteString("</table>")
+	right.WriteString("</table>")
+
+	key := cluster.Node("key")
+	key.Attr("shape", "plaintext")
+	key.Attr("fontname", "monospace")
+	key.Attr("fontsize", "10")
+	key.Attr("label", dot.HTML(left.String()))
+
+	key2 := cluster.Node("key2")
+	key2.Attr("shape", "plaintext")
+	key2.Attr("fontname", "monospace")
+	key2.Attr("fontsize", "10")
+	key2.Attr("label", dot.HTML(right.String()))
+
+	for i, entry := range entries {
+		e := graph.EdgeWithPorts(key, key2, fmt.Sprintf("i%d", i), fmt.Sprintf("i%d", i))
+		if entry.arrowhead != "" {
+			e.Attr("arrowhead", entry.arrowhead)
+		}
+		if entry.edgeStyle != "" {
+			e.Attr("style", entry.edgeStyle)
+		}
+	}
+}
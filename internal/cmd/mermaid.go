@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// renderMermaid writes a Mermaid flowchart equivalent to the Graphviz
+// output: a node per stage (or per layer, when showLayers is set, grouped
+// into subgraphs), dashed nodes for external images, and distinct arrow
+// styles per edge kind - FROM, COPY --from=, and each RUN --mount kind.
+func renderMermaid(g *dockerfileGraph, showLayers bool, direction, outputFile string) error {
+	if direction != "LR" && direction != "TD" {
+		return fmt.Errorf("invalid --direction %q, must be one of: LR, TD", direction)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "flowchart %s\n", direction)
+
+	for i, image := range g.externalImages {
+		fmt.Fprintf(&b, "    external_image_%d[\"%s\"]\n", i, image)
+		fmt.Fprintf(&b, "    style external_image_%d stroke-dasharray: 5 5\n", i)
+	}
+
+	stageHeadID := map[string]string{}
+	stageTailID := map[string]string{}
+	imageID := func(image string) string {
+		for i, existing := range g.externalImages {
+			if existing == image {
+				return fmt.Sprintf("external_image_%d", i)
+			}
+		}
+		return image
+	}
+
+	for _, s := range g.stages {
+		if !showLayers {
+			id := fmt.Sprintf("stage_%d", s.index)
+			fmt.Fprintf(&b, "    %s[%q]\n", id, s.name)
+			stageHeadID[s.name] = id
+			stageTailID[s.name] = id
+			continue
+		}
+
+		fmt.Fprintf(&b, "    subgraph cluster_stage_%d [%s]\n", s.index, s.name)
+		var prevID string
+		for i, l := range s.layers {
+			id := fmt.Sprintf("stage_%d_layer_%d", s.index, i)
+			fmt.Fprintf(&b, "        %s[%q]\n", id, truncateLabel(l.label))
+			if i == 0 {
+				stageHeadID[s.name] = id
+			}
+			stageTailID[s.name] = id
+			if i > 0 {
+				fmt.Fprintf(&b, "        %s --> %s\n", prevID, id)
+			}
+			prevID = id
+		}
+		b.WriteString("    end\n")
+	}
+
+	for _, s := range g.stages {
+		head := stageHeadID[s.name]
+
+		if s.fromIsStage {
+			fmt.Fprintf(&b, "    %s --> %s\n", stageTailID[s.from], head)
+		} else {
+			fmt.Fprintf(&b, "    %s --> %s\n", imageID(s.from), head)
+		}
+
+		for _, ref := range s.copyFrom {
+			from := imageID(ref.target)
+			if ref.isStage {
+				from = stageTailID[ref.target]
+			}
+			fmt.Fprintf(&b, "    %s -.->|COPY| %s\n", from, head)
+		}
+
+		for _, m := range s.mounts {
+			switch m.kind {
+			case mountKindCache, mountKindBind:
+				from := imageID(m.ref.target)
+				if m.ref.isStage {
+					from = stageTailID[m.ref.target]
+				}
+				fmt.Fprintf(&b, "    %s ==>|RUN --mount=type=%s| %s\n", from, m.kind, head)
+			case mountKindSecret, mountKindSSH:
+				fmt.Fprintf(&b, "    %s_%s([%s])\n", m.kind, m.id, m.id)
+				fmt.Fprintf(&b, "    %s_%s ==>|RUN --mount=type=%s| %s\n", m.kind, m.id, m.kind, head)
+			case mountKindTmpfs:
+				fmt.Fprintf(&b, "    %s -.->|RUN --mount=type=tmpfs| %s\n", head, head)
+			}
+		}
+	}
+
+	return os.WriteFile(outputFile, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func renderMermaidToString(t *testing.T, g *dockerfileGraph, showLayers bool, direction string) string {
+	t.Helper()
+
+	outputFile := t.TempDir() + "/out.mermaid"
+	if err := renderMermaid(g, showLayers, direction, outputFile); err != nil {
+		t.Fatalf("renderMermaid() error = %v", err)
+	}
+	out, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("reading %s: %v", outputFile, err)
+	}
+	return string(out)
+}
+
+func TestRenderMermaidRejectsAnInvalidDirection(t *testing.T) {
+	g, err := parseDockerfile("FROM alpine\n", nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	err = renderMermaid(g, false, "sideways", t.TempDir()+"/out.mermaid")
+	if err == nil {
+		t.Fatal("renderMermaid() error = nil, want invalid --direction error")
+	}
+	if want := `invalid --direction "sideways", must be one of: LR, TD`; err.Error() != want {
+		t.Errorf("renderMermaid() error = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestRenderMermaidGroupsLayersIntoSubgraphs(t *testing.T) {
+	g, err := parseDockerfile("FROM alpine AS build\nRUN echo one\nRUN echo two\n", nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	got := renderMermaidToString(t, g, true, "LR")
+
+	for _, want := range []string{
+		"subgraph cluster_stage_0 [build]",
+		`stage_0_layer_0["FROM alpine AS bu..."]`,
+		`stage_0_layer_1["RUN echo one"]`,
+		`stage_0_layer_2["RUN echo two"]`,
+		"stage_0_layer_0 --> stage_0_layer_1",
+		"stage_0_layer_1 --> stage_0_layer_2",
+		"    end\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("mermaid source missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderMermaidDrawsAnArrowStylePerMountKind(t *testing.T) {
+	content := `
+FROM golang:1.19 AS build
+RUN --mount=type=cache,from=buildcache,target=/go/pkg/mod/cache/ \
+    --mount=type=bind,from=vendor,source=/vendor,target=/vendor \
+    --mount=type=secret,id=npmrc \
+    --mount=type=ssh \
+    --mount=type=tmpfs,target=/tmp \
+    go build
+
+FROM scratch AS release
+COPY --from=build /app /app
+`
+	g, err := parseDockerfile(content, nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	got := renderMermaidToString(t, g, false, "LR")
+
+	for _, want := range []string{
+		"external_image_1 ==>|RUN --mount=type=cache| stage_0",
+		"external_image_2 ==>|RUN --mount=type=bind| stage_0",
+		"secret_npmrc([npmrc])",
+		"secret_npmrc ==>|RUN --mount=type=secret| stage_0",
+		"ssh_default([default])",
+		"ssh_default ==>|RUN --mount=type=ssh| stage_0",
+		"stage_0 -.->|RUN --mount=type=tmpfs| stage_0",
+		"stage_0 -.->|COPY| stage_1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("mermaid source missing %q:\n%s", want, got)
+		}
+	}
+}
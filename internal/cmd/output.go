@@ -0,0 +1,50 @@
+package cmd
+
+import "fmt"
+
+// outputFormat is a pflag.Value that restricts the -o/--output flag to the
+// set of formats this tool knows how to produce.
+type outputFormat struct {
+	value string
+}
+
+var validOutputFormats = []string{"canon", "dot", "mermaid", "pdf", "png", "svg"}
+
+func newOutputFormat() *outputFormat {
+	return &outputFormat{value: "pdf"}
+}
+
+func (o *outputFormat) String() string {
+	return o.value
+}
+
+func (o *outputFormat) Set(value string) error {
+	for _, valid := range validOutputFormats {
+		if value == valid {
+			o.value = value
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"invalid output format %q, must be one of: %s",
+		value, joinFormats(validOutputFormats),
+	)
+}
+
+// Type intentionally returns an empty string so that cobra's usage output
+// doesn't print a misleading type hint next to the flag - the allowed
+// values are already spelled out in the flag's usage text.
+func (o *outputFormat) Type() string {
+	return ""
+}
+
+func joinFormats(formats []string) string {
+	out := ""
+	for i, f := range formats {
+		if i > 0 {
+			out += ", "
+		}
+		out += f
+	}
+	return out
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/emicklei/dot"
+)
+
+// planStage is one stage's entry in the --plan output: what level BuildKit
+// could schedule it at, what it depends on, and whether it sits on the
+// critical (longest) path through the build.
+type planStage struct {
+	Stage          string   `json:"stage"`
+	Level          int      `json:"level"`
+	Predecessors   []string `json:"predecessors"`
+	OnCriticalPath bool     `json:"on_critical_path"`
+}
+
+// plan is the result of computePlan: a topological level per stage plus
+// the critical path through the weighted dependency DAG.
+type plan struct {
+	stages         []planStage
+	criticalStages map[string]bool
+	// criticalPred maps a stage on the critical path to the predecessor
+	// that chain runs through, so the graph can highlight that specific
+	// edge rather than every edge into the stage.
+	criticalPred map[string]string
+	totalWeight  float64
+}
+
+// computePlan assigns every stage a level - 1 + the max level of its
+// predecessors, with stages whose only dependency is an external image at
+// level 1 - and finds the longest path through the DAG using weights
+// (stage name -> duration in seconds). A nil/empty weights map treats
+// every stage as weight 1, so the "critical path" is simply the longest
+// chain of stages.
+func computePlan(g *dockerfileGraph, weights map[string]float64) *plan {
+	predecessors := map[string][]string{}
+	for _, s := range g.stages {
+		seen := map[string]bool{}
+		add := func(name string) {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				predecessors[s.name] = append(predecessors[s.name], name)
+			}
+		}
+		if s.fromIsStage {
+			add(s.from)
+		}
+		for _, ref := range s.copyFrom {
+			if ref.isStage {
+				add(ref.target)
+			}
+		}
+		for _, m := range s.mounts {
+			if (m.kind == mountKindCache || m.kind == mountKindBind) && m.ref.isStage {
+				add(m.ref.target)
+			}
+		}
+	}
+
+	levels := map[string]int{}
+	dist := map[string]float64{}
+	bestPred := map[string]string{}
+
+	// g.stages is already in declaration order, and a stage can only
+	// depend on stages declared earlier in the file, so a single forward
+	// pass is enough - no need for a separate topological sort.
+	for _, s := range g.stages {
+		level := 1
+		weight := weights[s.name]
+		if weight == 0 {
+			weight = 1
+		}
+		best := 0.0
+		var bestName string
+		for _, pred := range predecessors[s.name] {
+			if levels[pred]+1 > level {
+				level = levels[pred] + 1
+			}
+			if dist[pred] > best {
+				best = dist[pred]
+				bestName = pred
+			}
+		}
+		levels[s.name] = level
+		dist[s.name] = best + weight
+		bestPred[s.name] = bestName
+	}
+
+	var end string
+	var total float64
+	for _, s := range g.stages {
+		if d := dist[s.name]; d > total {
+			total = d
+			end = s.name
+		}
+	}
+
+	critical := map[string]bool{}
+	for name := end; name != ""; name = bestPred[name] {
+		critical[name] = true
+	}
+
+	stages := make([]planStage, 0, len(g.stages))
+	for _, s := range g.stages {
+		stages = append(stages, planStage{
+			Stage:          s.name,
+			Level:          levels[s.name],
+			Predecessors:   predecessors[s.name],
+			OnCriticalPath: critical[s.name],
+		})
+	}
+
+	return &plan{
+		stages:         stages,
+		criticalStages: critical,
+		criticalPred:   bestPred,
+		totalWeight:    total,
+	}
+}
+
+// marshalPlanJSON renders the machine-readable Dockerfile.plan.json sidecar
+// consumed by CI.
+func marshalPlanJSON(p *plan) ([]byte, error) {
+	out, err := json.MarshalIndent(p.stages, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling plan: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+const (
+	criticalPathColor = "red"
+	criticalPenWidth  = "2"
+)
+
+// applyPlan groups nodes of the same level onto a shared rank - so columns
+// in the rendered graph line up with how much BuildKit could build in
+// parallel - with external images grouped into level 0 since they're
+// already available before any stage starts building. It also highlights
+// the critical path computed by computePlan in red. stageNode and
+// externalImageNode are the representative node per stage and per external
+// image returned by buildGraph.
+func applyPlan(
+	graph *dot.Graph,
+	g *dockerfileGraph,
+	stageNode map[string]dot.Node,
+	externalImageNode map[string]dot.Node,
+	p *plan,
+) {
+	byLevel := map[int][]dot.Node{}
+	for _, image := range g.externalImages {
+		byLevel[0] = append(byLevel[0], externalImageNode[image])
+	}
+	for _, s := range p.stages {
+		byLevel[s.Level] = append(byLevel[s.Level], stageNode[s.Stage])
+	}
+
+	for level, nodes := range byLevel {
+		graph.AddToSameRank(fmt.Sprintf("level_%d", level), nodes...)
+	}
+
+	for stageName := range p.criticalStages {
+		n := stageNode[stageName]
+		n.Attr("color", criticalPathColor)
+		n.Attr("penwidth", criticalPenWidth)
+
+		// This overlays a second, highlighted edge on top of the one
+		// buildGraph already drew for this dependency rather than
+		// mutating it in place - graphviz renders the pair as a single
+		// thicker red line, which is the effect we want.
+		if pred := p.criticalPred[stageName]; pred != "" {
+			if from, ok := stageNode[pred]; ok {
+				e := graph.Edge(from, n)
+				e.Attr("color", criticalPathColor)
+				e.Attr("penwidth", criticalPenWidth)
+			}
+		}
+	}
+
+	caption := graph.Node("plan_caption")
+	caption.Attr("shape", "plaintext")
+	caption.Attr("label", fmt.Sprintf("critical path: %gs", p.totalWeight))
+}
+
+// loadWeights reads the --weights JSON file, a flat {"stage": seconds}
+// object. An empty path is not an error - every stage is simply weighted 1.
+func loadWeights(path string) (map[string]float64, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read --weights file: %w", err)
+	}
+
+	var weights map[string]float64
+	if err := json.Unmarshal(content, &weights); err != nil {
+		return nil, fmt.Errorf("could not parse --weights file: %w", err)
+	}
+
+	return weights, nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/emicklei/dot"
+)
+
+func TestComputePlanLevelsAndCriticalPath(t *testing.T) {
+	content := `
+FROM alpine AS base
+
+FROM base AS a
+RUN echo a
+
+FROM base AS b
+RUN echo b
+
+FROM scratch AS final
+COPY --from=a /a /a
+COPY --from=b /b /b
+`
+
+	graph, err := parseDockerfile(content, nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	p := computePlan(graph, map[string]float64{"a": 10, "b": 1})
+
+	levels := map[string]int{}
+	for _, s := range p.stages {
+		levels[s.Stage] = s.Level
+	}
+
+	wantLevels := map[string]int{"base": 1, "a": 2, "b": 2, "final": 3}
+	for name, want := range wantLevels {
+		if got := levels[name]; got != want {
+			t.Errorf("levels[%q] = %d, want %d", name, got, want)
+		}
+	}
+
+	if !p.criticalStages["a"] || !p.criticalStages["final"] {
+		t.Errorf("expected the heavier \"a\" branch on the critical path, got %v", p.criticalStages)
+	}
+	if p.criticalStages["b"] {
+		t.Errorf("lighter \"b\" branch should not be on the critical path")
+	}
+
+	// base(1) + a(10) + final(1) = 12
+	if p.totalWeight != 12 {
+		t.Errorf("totalWeight = %g, want 12", p.totalWeight)
+	}
+}
+
+func TestComputePlanBreaksCriticalPathTiesDeterministically(t *testing.T) {
+	content := `
+FROM alpine AS base
+
+FROM base AS a
+RUN echo a
+
+FROM base AS b
+RUN echo b
+
+FROM scratch AS final
+COPY --from=a /a /a
+COPY --from=b /b /b
+`
+
+	graph, err := parseDockerfile(content, nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	// "a" and "b" are equal-weight branches, so the tie must resolve to
+	// whichever one was seen first in g.stages, not to whichever map
+	// iteration happened to visit last.
+	for i := 0; i < 50; i++ {
+		p := computePlan(graph, nil)
+		if !p.criticalStages["a"] {
+			t.Fatalf("run %d: expected \"a\" on the critical path, got %v", i, p.criticalStages)
+		}
+		if p.criticalStages["b"] {
+			t.Fatalf("run %d: \"b\" should not be on the critical path", i)
+		}
+	}
+}
+
+func TestApplyPlanGroupsExternalImagesIntoLevelZero(t *testing.T) {
+	content := `
+FROM alpine AS base
+FROM base AS next
+`
+	g, err := parseDockerfile(content, nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	graph, stageNode, externalImageNode := buildGraph(g, false, false)
+	p := computePlan(g, nil)
+	applyPlan(graph, g, stageNode, externalImageNode, p)
+
+	source := graph.String()
+	alpineSeq := nodeSeq(t, source, externalImageNode["alpine"])
+	baseSeq := nodeSeq(t, source, stageNode["base"])
+	nextSeq := nodeSeq(t, source, stageNode["next"])
+
+	alpineGroup := rankGroupOf(t, source, alpineSeq)
+	if strings.Contains(alpineGroup, baseSeq+";") || strings.Contains(alpineGroup, nextSeq+";") {
+		t.Errorf("\"alpine\"'s rank group %q should not also contain a stage node", alpineGroup)
+	}
+}
+
+// nodeSeq returns the "nN" sequence identifier dot assigned a node, by
+// looking up its declaration line (e.g. `n2[label="base",...]`) in the
+// rendered source - the public dot.Node type doesn't expose it directly.
+func nodeSeq(t *testing.T, source string, n dot.Node) string {
+	t.Helper()
+	needle := `label="` + n.Value("label").(string) + `"`
+	idx := strings.Index(source, needle)
+	if idx == -1 {
+		t.Fatalf("node with %s not found in:\n%s", needle, source)
+	}
+	lineStart := strings.LastIndexByte(source[:idx], '\n') + 1
+	bracket := strings.IndexByte(source[lineStart:], '[')
+	return strings.TrimSpace(source[lineStart : lineStart+bracket])
+}
+
+// rankGroupOf returns the `{rank=same; ...};` line that mentions the given
+// node sequence id, failing the test if none does.
+func rankGroupOf(t *testing.T, source, seq string) string {
+	t.Helper()
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "{rank=same;") && strings.Contains(line, seq+";") {
+			return line
+		}
+	}
+	t.Fatalf("no {rank=same; ...} group contains node %s in:\n%s", seq, source)
+	return ""
+}
+
+func TestComputePlanDefaultsToUnitWeights(t *testing.T) {
+	content := `
+FROM alpine AS base
+FROM base AS next
+`
+	graph, err := parseDockerfile(content, nil, nil)
+	if err != nil {
+		t.Fatalf("parseDockerfile() error = %v", err)
+	}
+
+	p := computePlan(graph, nil)
+	if p.totalWeight != 2 {
+		t.Errorf("totalWeight = %g, want 2", p.totalWeight)
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/emicklei/dot"
+)
+
+// render writes the graph to outputFile in the requested format. For "dot"
+// it writes the raw Graphviz source directly; every other format is
+// produced by piping that source through the system `dot` binary.
+func render(graph *dot.Graph, format, outputFile string, dpi int) error {
+	source := graph.String()
+
+	if format == "dot" {
+		return os.WriteFile(outputFile, []byte(source), 0o644)
+	}
+
+	if format == "svg" {
+		svg, err := runGraphviz(source, "svg", dpi)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(outputFile, []byte(inlineEdgeClassStyles(svg)), 0o644)
+	}
+
+	out, err := runGraphviz(source, format, dpi)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputFile, out, 0o644)
+}
+
+// runGraphviz pipes dot source through the system `dot` binary and returns
+// the rendered output.
+func runGraphviz(source, format string, dpi int) ([]byte, error) {
+	args := []string{"-T" + format}
+	if format == "png" {
+		args = append(args, fmt.Sprintf("-Gdpi=%d", dpi))
+	}
+
+	// #nosec G204 -- format is restricted to a fixed allowlist by outputFormat.Set
+	cmd := exec.Command("dot", args...)
+	cmd.Stdin = strings.NewReader(source)
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running graphviz: %w: %s", err, stderr.String())
+	}
+
+	return out.Bytes(), nil
+}
+
+// edgeClassStyles are the CSS rules inlined into SVG output so that the
+// edgeKind classes set in buildGraph (when withClasses is true) are
+// actually styleable without any external stylesheet.
+const edgeClassStyles = `<style>
+.edge-from path { stroke: #555555; }
+.edge-copy path { stroke: #2a6099; }
+.edge-mount path { stroke: #a65d00; stroke-dasharray: 4,2; }
+</style>
+`
+
+// inlineEdgeClassStyles injects a <style> block right after the opening
+// <svg> tag so the "class" attributes graphviz copies onto each edge's <g>
+// element are actually styled.
+func inlineEdgeClassStyles(svg []byte) []byte {
+	svgTag := bytes.Index(svg, []byte("<svg"))
+	if svgTag == -1 {
+		return svg
+	}
+	closing := bytes.IndexByte(svg[svgTag:], '>')
+	if closing == -1 {
+		return svg
+	}
+	idx := svgTag + closing + 1 // just past the <svg ...> tag's '>'
+
+	out := make([]byte, 0, len(svg)+len(edgeClassStyles))
+	out = append(out, svg[:idx]...)
+	out = append(out, edgeClassStyles...)
+	out = append(out, svg[idx:]...)
+	return out
+}
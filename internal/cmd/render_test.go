@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInlineEdgeClassStylesInjectsStyleAfterTheSVGTag(t *testing.T) {
+	svg := []byte(`<?xml version="1.0"?>
+<svg width="100" height="100" viewBox="0 0 100 100">
+<g id="edge1" class="edge-from"><path d="M0,0 L1,1"/></g>
+</svg>
+`)
+
+	got := string(inlineEdgeClassStyles(svg))
+
+	wantStyleIdx := strings.Index(got, edgeClassStyles)
+	svgTagIdx := strings.Index(got, "<svg")
+	svgTagEnd := strings.IndexByte(got[svgTagIdx:], '>') + svgTagIdx + 1
+
+	if wantStyleIdx == -1 {
+		t.Fatalf("output missing the edge class <style> block:\n%s", got)
+	}
+	if wantStyleIdx != svgTagEnd {
+		t.Errorf("style block at %d, want immediately after the <svg ...> tag at %d:\n%s", wantStyleIdx, svgTagEnd, got)
+	}
+	if !strings.Contains(got, `class="edge-from"`) {
+		t.Errorf("original edge class attribute should be preserved:\n%s", got)
+	}
+}
+
+func TestInlineEdgeClassStylesLeavesSVGWithoutATagUnchanged(t *testing.T) {
+	svg := []byte("not actually svg")
+
+	got := inlineEdgeClassStyles(svg)
+
+	if string(got) != string(svg) {
+		t.Errorf("inlineEdgeClassStyles() = %q, want input unchanged", got)
+	}
+}
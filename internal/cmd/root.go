@@ -0,0 +1,290 @@
+// Package cmd implements the dockerfilegraph CLI: it parses a Dockerfile,
+// builds a Graphviz representation of its stages and their dependencies,
+// and renders it to disk.
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/patrickhoefler/dockerfilegraph/internal/version"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// NewRootCmd builds the dockerfilegraph CLI command. out is where normal
+// output (success messages, --help, --version) is written; fs is the
+// filesystem the Dockerfile is read from and the graph is written to, so
+// that tests can run against an in-memory filesystem.
+func NewRootCmd(out io.Writer, fs afero.Fs) *cobra.Command {
+	var (
+		dpi        int
+		filenames  []string
+		layers     bool
+		legend     bool
+		output     = newOutputFormat()
+		showVers   bool
+		buildArgs  []string
+		direction  string
+		showPlan   bool
+		weightsArg string
+		bakeFile   string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dockerfilegraph",
+		Short: "Visualize your Dockerfile",
+		Long: `dockerfilegraph visualizes your multi-stage Dockerfile.
+It outputs a graph representation of the build process.`,
+		SilenceUsage: false,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if showVers {
+				return printVersion(out)
+			}
+
+			return run(cmd, fs, runOptions{
+				dpi:       dpi,
+				filenames: filenames,
+				layers:    layers,
+				legend:    legend,
+				output:    output.String(),
+				buildArgs: buildArgs,
+				direction: direction,
+				plan:      showPlan,
+				weights:   weightsArg,
+				bake:      bakeFile,
+			})
+		},
+	}
+
+	cmd.SetOut(out)
+
+	cmd.Flags().IntVarP(&dpi, "dpi", "d", 96, "dots per inch of the PNG export")
+	cmd.Flags().StringArrayVarP(
+		&filenames, "filename", "f", []string{"Dockerfile"},
+		"name of the Dockerfile(s) to graph; glob patterns and repeated flags are supported",
+	)
+	cmd.Flags().BoolVar(&layers, "layers", false, "display all layers")
+	cmd.Flags().BoolVar(&legend, "legend", false, "add a legend")
+	cmd.Flags().VarP(output, "output", "o", "output file format, one of: "+joinFormats(validOutputFormats))
+	cmd.Flags().BoolVar(&showVers, "version", false, "display the version of dockerfilegraph")
+	cmd.Flags().StringArrayVar(
+		&buildArgs, "build-arg", nil,
+		"set a build-time ARG override, in KEY=VALUE form (can be repeated)",
+	)
+	cmd.Flags().StringVar(
+		&direction, "direction", "LR",
+		"direction of the mermaid flowchart, one of: LR, TD",
+	)
+	cmd.Flags().BoolVar(
+		&showPlan, "plan", false,
+		"group stages into levels by build parallelism and highlight the critical path",
+	)
+	cmd.Flags().StringVar(
+		&weightsArg, "weights", "",
+		"path to a JSON file mapping stage name to build duration in seconds, used with --plan",
+	)
+	cmd.Flags().StringVar(
+		&bakeFile, "bake", "",
+		"parse a docker-bake.hcl or docker-bake.json file and graph its targets across files",
+	)
+
+	return cmd
+}
+
+// Execute runs the root command against the real filesystem and exits the
+// process with a non-zero status on error.
+func Execute() {
+	cmd := NewRootCmd(os.Stdout, afero.NewOsFs())
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func printVersion(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	return enc.Encode(version.Get())
+}
+
+type runOptions struct {
+	dpi       int
+	filenames []string
+	layers    bool
+	legend    bool
+	output    string
+	buildArgs []string
+	direction string
+	plan      bool
+	weights   string
+	bake      string
+}
+
+func run(cmd *cobra.Command, fs afero.Fs, opts runOptions) error {
+	filenames, err := resolveFilenames(fs, opts.filenames)
+	if err != nil {
+		return err
+	}
+
+	if opts.bake != "" || len(filenames) > 1 {
+		return runCombined(cmd, fs, opts, filenames)
+	}
+
+	filename := "Dockerfile"
+	if len(filenames) == 1 {
+		filename = filenames[0]
+	}
+
+	content, err := afero.ReadFile(fs, filename)
+	if err != nil {
+		return fmt.Errorf("could not find a Dockerfile at %s", filename)
+	}
+
+	buildArgs, err := parseBuildArgs(opts.buildArgs)
+	if err != nil {
+		return err
+	}
+
+	dockerfileGraph, err := parseDockerfile(string(content), buildArgs, cmd.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+
+	outputFile := filename + "." + opts.output
+
+	if opts.output == "mermaid" {
+		if err := renderMermaid(dockerfileGraph, opts.layers, opts.direction, outputFile); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "Successfully created %s\n", outputFile)
+		return nil
+	}
+
+	graph, stageNode, externalImageNode := buildGraph(dockerfileGraph, opts.layers, opts.output == "svg")
+	if opts.legend {
+		addLegend(graph, dockerfileGraph)
+	}
+
+	if opts.plan {
+		weights, err := loadWeights(opts.weights)
+		if err != nil {
+			return err
+		}
+
+		p := computePlan(dockerfileGraph, weights)
+		applyPlan(graph, dockerfileGraph, stageNode, externalImageNode, p)
+
+		planJSON, err := marshalPlanJSON(p)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filename+".plan.json", planJSON, 0o644); err != nil {
+			return err
+		}
+	}
+
+	if err := render(graph, opts.output, outputFile, opts.dpi); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Successfully created %s\n", outputFile)
+	return nil
+}
+
+// resolveFilenames expands --filename's glob patterns and de-duplicates
+// repeated flags into a concrete, ordered list of Dockerfile paths. A
+// pattern that matches nothing is kept as-is, so a plain typo still
+// surfaces the usual "could not find a Dockerfile" error instead of
+// silently vanishing.
+func resolveFilenames(fs afero.Fs, patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var result []string
+	for _, pattern := range patterns {
+		matches, err := afero.Glob(fs, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --filename pattern %q: %w", pattern, err)
+		}
+		if matches == nil {
+			matches = []string{pattern}
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				result = append(result, m)
+			}
+		}
+	}
+	return result, nil
+}
+
+// runCombined handles multi-Dockerfile and --bake mode: it parses every
+// Dockerfile named by --filename (or discovered via --bake), folds them
+// into one graph connected by cross-file dependency edges, and writes a
+// single combined output file (bake.pdf, bake.canon, ...).
+func runCombined(cmd *cobra.Command, fs afero.Fs, opts runOptions, filenames []string) error {
+	var targets []bakeTargetResolved
+	if opts.bake != "" {
+		raw, err := parseBakeFile(opts.bake)
+		if err != nil {
+			return err
+		}
+		targets = resolveBakeTargets(raw)
+
+		filenames = filenames[:0]
+		for _, t := range targets {
+			filenames = append(filenames, t.dockerfile)
+		}
+	}
+
+	bakeContextsFor := func(filename string) map[string]string {
+		for _, t := range targets {
+			if t.dockerfile == filename {
+				return t.contexts
+			}
+		}
+		return nil
+	}
+
+	files := make([]combinedFile, 0, len(filenames))
+	for _, filename := range filenames {
+		content, err := afero.ReadFile(fs, filename)
+		if err != nil {
+			return fmt.Errorf("could not find a Dockerfile at %s", filename)
+		}
+
+		dockerfileGraph, err := parseDockerfile(string(content), nil, cmd.ErrOrStderr())
+		if err != nil {
+			return err
+		}
+
+		files = append(files, combinedFile{
+			filename:     filename,
+			graph:        dockerfileGraph,
+			bakeContexts: bakeContextsFor(filename),
+		})
+	}
+
+	graph := buildCombinedGraph(files)
+
+	outputFile := "bake." + opts.output
+	if err := render(graph, opts.output, outputFile, opts.dpi); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Successfully created %s\n", outputFile)
+	return nil
+}
+
+func parseBuildArgs(raw []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, kv := range raw {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --build-arg %q, must be in KEY=VALUE form", kv)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -12,27 +13,33 @@ import (
 )
 
 type test struct {
-	name               string
-	cliArgs            []string
-	dockerfileContent  string
-	wantErr            bool
-	wantOut            string
-	wantOutRegex       string
-	wantOutFile        string
-	wantOutFileContent string
+	name                string
+	cliArgs             []string
+	dockerfileContent   string
+	wantErr             bool
+	wantOut             string
+	wantOutRegex        string
+	wantOutFile         string
+	wantOutFileContent  string
+	wantOutFileContains []string
 }
 
 var usage = `Usage:
   dockerfilegraph [flags]
 
 Flags:
-  -d, --dpi int           dots per inch of the PNG export (default 96)
-  -f, --filename string   name of the Dockerfile (default "Dockerfile")
-  -h, --help              help for dockerfilegraph
-      --layers            display all layers (default false)
-      --legend            add a legend (default false)
-  -o, --output            output file format, one of: canon, dot, pdf, png (default pdf)
-      --version           display the version of dockerfilegraph
+      --bake string             parse a docker-bake.hcl or docker-bake.json file and graph its targets across files
+      --build-arg stringArray   set a build-time ARG override, in KEY=VALUE form (can be repeated)
+      --direction string        direction of the mermaid flowchart, one of: LR, TD (default "LR")
+  -d, --dpi int                 dots per inch of the PNG export (default 96)
+  -f, --filename stringArray    name of the Dockerfile(s) to graph; glob patterns and repeated flags are supported (default [Dockerfile])
+  -h, --help                    help for dockerfilegraph
+      --layers                  display all layers
+      --legend                  add a legend
+  -o, --output                  output file format, one of: canon, dot, mermaid, pdf, png, svg (default pdf)
+      --plan                    group stages into levels by build parallelism and highlight the critical path
+      --version                 display the version of dockerfilegraph
+      --weights string          path to a JSON file mapping stage name to build duration in seconds, used with --plan
 `
 
 // Taken from example/Dockerfile.
@@ -177,6 +184,74 @@ It outputs a graph representation of the build process.
 			wantOut:     "Successfully created Dockerfile.png\n",
 			wantOutFile: "Dockerfile.png",
 		},
+		{
+			name:        "output flag svg",
+			cliArgs:     []string{"--output", "svg"},
+			wantOut:     "Successfully created Dockerfile.svg\n",
+			wantOutFile: "Dockerfile.svg",
+			wantOutFileContains: []string{
+				"<svg",
+				".edge-from path { stroke: #555555; }",
+				".edge-copy path { stroke: #2a6099; }",
+				".edge-mount path { stroke: #a65d00; stroke-dasharray: 4,2; }",
+			},
+		},
+		{
+			name:        "output flag mermaid",
+			cliArgs:     []string{"--output", "mermaid"},
+			wantOut:     "Successfully created Dockerfile.mermaid\n",
+			wantOutFile: "Dockerfile.mermaid",
+			wantOutFileContent: `flowchart LR
+    external_image_0["ubuntu:latest"]
+    style external_image_0 stroke-dasharray: 5 5
+    external_image_1["golang:1.19"]
+    style external_image_1 stroke-dasharray: 5 5
+    external_image_2["buildcache"]
+    style external_image_2 stroke-dasharray: 5 5
+    external_image_3["scratch"]
+    style external_image_3 stroke-dasharray: 5 5
+    stage_0["ubuntu"]
+    stage_1["build"]
+    stage_2["release"]
+    external_image_0 --> stage_0
+    external_image_1 --> stage_1
+    external_image_2 ==>|RUN --mount=type=cache| stage_1
+    external_image_3 --> stage_2
+    stage_0 -.->|COPY| stage_2
+    stage_1 -.->|COPY| stage_2
+`,
+		},
+		{
+			name:        "output flag mermaid with direction",
+			cliArgs:     []string{"--output", "mermaid", "--direction", "TD"},
+			wantOut:     "Successfully created Dockerfile.mermaid\n",
+			wantOutFile: "Dockerfile.mermaid",
+			wantOutFileContent: `flowchart TD
+    external_image_0["ubuntu:latest"]
+    style external_image_0 stroke-dasharray: 5 5
+    external_image_1["golang:1.19"]
+    style external_image_1 stroke-dasharray: 5 5
+    external_image_2["buildcache"]
+    style external_image_2 stroke-dasharray: 5 5
+    external_image_3["scratch"]
+    style external_image_3 stroke-dasharray: 5 5
+    stage_0["ubuntu"]
+    stage_1["build"]
+    stage_2["release"]
+    external_image_0 --> stage_0
+    external_image_1 --> stage_1
+    external_image_2 ==>|RUN --mount=type=cache| stage_1
+    external_image_3 --> stage_2
+    stage_0 -.->|COPY| stage_2
+    stage_1 -.->|COPY| stage_2
+`,
+		},
+		{
+			name:         "direction flag with invalid value",
+			cliArgs:      []string{"--output", "mermaid", "--direction", "sideways"},
+			wantErr:      true,
+			wantOutRegex: `^Error: invalid --direction "sideways"`,
+		},
 		{
 			name:        "filename flag",
 			cliArgs:     []string{"--filename", "subdir/../Dockerfile"},
@@ -187,7 +262,7 @@ It outputs a graph representation of the build process.
 			name:         "filename flag with missing Dockerfile",
 			cliArgs:      []string{"--filename", "Dockerfile.missing"},
 			wantErr:      true,
-			wantOutRegex: "^Error: could not find a Dockerfile at .+Dockerfile.missing\n",
+			wantOutRegex: "^Error: could not find a Dockerfile at .*Dockerfile.missing\n",
 		},
 		{
 			name:        "layers flag",
@@ -383,6 +458,132 @@ It outputs a graph representation of the build process.
 }
 `,
 		},
+		{
+			name: "build args are resolved in FROM and COPY --from",
+			cliArgs: []string{
+				"--build-arg", "GO_VERSION=1.19",
+				"--output", "canon",
+			},
+			dockerfileContent: `
+ARG BASE=alpine:3.18
+ARG GO_VERSION
+
+FROM ${BASE} AS builder
+ARG GO_VERSION
+RUN echo ${GO_VERSION}
+
+FROM golang:${GO_VERSION} AS final
+COPY --from=builder /out /out
+`,
+			wantOut:     "Successfully created Dockerfile.canon\n",
+			wantOutFile: "Dockerfile.canon",
+			wantOutFileContains: []string{
+				`label="alpine:3.18"`,
+				`label="golang:1.19"`,
+			},
+		},
+		{
+			name: "unresolved build arg keeps its literal form and warns",
+			cliArgs: []string{
+				"--output", "canon",
+			},
+			dockerfileContent: `
+ARG BASE
+
+FROM ${BASE} AS builder
+`,
+			wantOut:     "warning: could not resolve all variables in \"${BASE}\"\nSuccessfully created Dockerfile.canon\n",
+			wantOutFile: "Dockerfile.canon",
+			wantOutFileContains: []string{
+				`label="${BASE}"`,
+			},
+		},
+		{
+			name:        "plan flag",
+			cliArgs:     []string{"--plan", "-o", "canon"},
+			wantOut:     "Successfully created Dockerfile.canon\n",
+			wantOutFile: "Dockerfile.canon",
+			wantOutFileContains: []string{
+				"color=red",
+				"penwidth=2",
+			},
+		},
+		{
+			name:    "layers flag with a single heredoc",
+			cliArgs: []string{"--layers", "-o", "canon"},
+			dockerfileContent: `
+FROM alpine AS build
+RUN <<EOF
+apt-get update
+apt-get install -y foo
+EOF
+`,
+			wantOut:     "Successfully created Dockerfile.canon\n",
+			wantOutFile: "Dockerfile.canon",
+			wantOutFileContains: []string{
+				`label="RUN <<EOF (2 lines)"`,
+			},
+		},
+		{
+			name:    "layers flag with multiple heredocs in one RUN",
+			cliArgs: []string{"--layers", "-o", "canon"},
+			dockerfileContent: `
+FROM alpine AS build
+RUN cat <<FILE1 > a && cat <<FILE2 > b
+line one
+FILE1
+line two
+line three
+FILE2
+`,
+			wantOut:     "Successfully created Dockerfile.canon\n",
+			wantOutFile: "Dockerfile.canon",
+			wantOutFileContains: []string{
+				`label="RUN cat <<FILE1 (1 line) > a && cat <<FILE2 (2 lines) > b"`,
+			},
+		},
+		{
+			name:    "layers flag with the <<- indent-stripping heredoc form",
+			cliArgs: []string{"--layers", "-o", "canon"},
+			dockerfileContent: `
+FROM alpine AS build
+RUN <<-EOF
+	apt-get update
+	EOF
+`,
+			wantOut:     "Successfully created Dockerfile.canon\n",
+			wantOutFile: "Dockerfile.canon",
+			wantOutFileContains: []string{
+				`label="RUN <<EOF (1 line)"`,
+			},
+		},
+		{
+			name:    "legend flag with every mount kind",
+			cliArgs: []string{"--legend", "-o", "canon"},
+			dockerfileContent: `
+FROM golang:1.19 AS build
+RUN --mount=type=cache,from=buildcache,target=/go/pkg/mod/cache/ \
+    --mount=type=bind,from=vendor,source=/vendor,target=/vendor \
+    --mount=type=secret,id=npmrc \
+    --mount=type=ssh \
+    --mount=type=tmpfs,target=/tmp \
+    go build
+
+FROM scratch AS release
+COPY --from=build /app /app
+`,
+			wantOut:     "Successfully created Dockerfile.canon\n",
+			wantOutFile: "Dockerfile.canon",
+			wantOutFileContains: []string{
+				"RUN --mount=type=bind,from=...",
+				"RUN --mount=type=secret,id=...",
+				"RUN --mount=type=ssh",
+				"RUN --mount=type=tmpfs",
+				"arrowhead=obox",
+				"arrowhead=odot",
+				"arrowhead=odiamond",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -426,12 +627,24 @@ It outputs a graph representation of the build process.
 					t.Errorf("Output mismatch (-want +got):\n%s", diff)
 				}
 			}
+
+			for _, want := range tt.wantOutFileContains {
+				outFileContent, err := os.ReadFile(tt.wantOutFile)
+				if err != nil {
+					t.Errorf("%s: %v", tt.name, err)
+					continue
+				}
+				if !strings.Contains(string(outFileContent), want) {
+					t.Errorf("%s: %s missing %q", tt.name, tt.wantOutFile, want)
+				}
+			}
 		})
 
 		// Cleanup
 		if tt.wantOutFile != "" {
 			os.Remove(tt.wantOutFile)
 		}
+		os.Remove("Dockerfile.plan.json")
 	}
 }
 
@@ -462,6 +675,127 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+func TestPlanWritesWeightedSidecarFile(t *testing.T) {
+	inputFS := afero.NewMemMapFs()
+	_ = afero.WriteFile(inputFS, "Dockerfile", []byte(dockerfileContent), 0644)
+
+	weightsFile := "weights.json"
+	_ = os.WriteFile(weightsFile, []byte(`{"build": 10}`), 0644)
+	defer os.Remove(weightsFile)
+
+	buf := new(bytes.Buffer)
+	command := cmd.NewRootCmd(buf, inputFS)
+	command.SetArgs([]string{"--plan", "--weights", weightsFile, "-o", "canon"})
+	command.SetOut(buf)
+	command.SetErr(buf)
+
+	if err := command.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	defer os.Remove("Dockerfile.canon")
+	defer os.Remove("Dockerfile.plan.json")
+
+	got, err := os.ReadFile("Dockerfile.plan.json")
+	if err != nil {
+		t.Fatalf("reading Dockerfile.plan.json: %v", err)
+	}
+
+	want := `[
+  {
+    "stage": "ubuntu",
+    "level": 1,
+    "predecessors": null,
+    "on_critical_path": false
+  },
+  {
+    "stage": "build",
+    "level": 1,
+    "predecessors": null,
+    "on_critical_path": true
+  },
+  {
+    "stage": "release",
+    "level": 2,
+    "predecessors": [
+      "ubuntu",
+      "build"
+    ],
+    "on_critical_path": true
+  }
+]
+`
+	if diff := cmp.Diff(want, string(got)); diff != "" {
+		t.Errorf("Dockerfile.plan.json mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestMultipleFilenamesProduceACombinedGraph(t *testing.T) {
+	inputFS := afero.NewMemMapFs()
+	_ = afero.WriteFile(inputFS, "base/Dockerfile", []byte("FROM alpine AS base\nRUN echo base\n"), 0644)
+	_ = afero.WriteFile(inputFS, "app/Dockerfile", []byte("FROM base AS app\nRUN echo app\n"), 0644)
+
+	buf := new(bytes.Buffer)
+	command := cmd.NewRootCmd(buf, inputFS)
+	command.SetArgs([]string{
+		"--filename", "base/Dockerfile",
+		"--filename", "app/Dockerfile",
+		"-o", "canon",
+	})
+	command.SetOut(buf)
+	command.SetErr(buf)
+
+	if err := command.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	defer os.Remove("bake.canon")
+
+	if want, got := "Successfully created bake.canon\n", buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if _, err := os.Stat("bake.canon"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBakeFileProducesACombinedGraph(t *testing.T) {
+	inputFS := afero.NewMemMapFs()
+	_ = afero.WriteFile(inputFS, "base/Dockerfile", []byte("FROM alpine AS base\nRUN echo base\n"), 0644)
+	_ = afero.WriteFile(inputFS, "app/Dockerfile", []byte("FROM base AS app\nRUN echo app\n"), 0644)
+
+	bakeFile := "docker-bake.hcl"
+	_ = os.WriteFile(bakeFile, []byte(`
+target "base" {
+  dockerfile = "base/Dockerfile"
+}
+
+target "app" {
+  dockerfile = "app/Dockerfile"
+  contexts = {
+    base = "target:base"
+  }
+}
+`), 0644)
+	defer os.Remove(bakeFile)
+
+	buf := new(bytes.Buffer)
+	command := cmd.NewRootCmd(buf, inputFS)
+	command.SetArgs([]string{"--bake", bakeFile, "-o", "canon"})
+	command.SetOut(buf)
+	command.SetErr(buf)
+
+	if err := command.Execute(); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	defer os.Remove("bake.canon")
+
+	if want, got := "Successfully created bake.canon\n", buf.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+	if _, err := os.Stat("bake.canon"); err != nil {
+		t.Error(err)
+	}
+}
+
 func checkWantOut(t *testing.T, tt test, buf *bytes.Buffer) {
 	if tt.wantOut == "" && tt.wantOutRegex == "" {
 		t.Fatalf("Either wantOut or wantOutRegex must be set")
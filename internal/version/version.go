@@ -0,0 +1,33 @@
+// Package version holds build-time version information that is injected via
+// -ldflags when a release binary is built.
+package version
+
+import (
+	"crypto/sha1" //nolint:gosec // used only to produce a deterministic dev placeholder
+	"fmt"
+)
+
+// These variables are meant to be overridden at build time via
+// -ldflags "-X github.com/patrickhoefler/dockerfilegraph/internal/version.GitVersion=...".
+var (
+	GitVersion = "v0.0.0-dev"
+	GitCommit  = fmt.Sprintf("%x", sha1.Sum(nil)) //nolint:gosec
+	BuildDate  = "0000-00-00T00:00:00Z"
+)
+
+// Info is the JSON-serializable representation of the build version
+// that's printed by the --version flag.
+type Info struct {
+	GitVersion string `json:"GitVersion"`
+	GitCommit  string `json:"GitCommit"`
+	BuildDate  string `json:"BuildDate"`
+}
+
+// Get returns the current build version information.
+func Get() Info {
+	return Info{
+		GitVersion: GitVersion,
+		GitCommit:  GitCommit,
+		BuildDate:  BuildDate,
+	}
+}
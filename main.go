@@ -0,0 +1,7 @@
+package main
+
+import "github.com/patrickhoefler/dockerfilegraph/internal/cmd"
+
+func main() {
+	cmd.Execute()
+}